@@ -0,0 +1,491 @@
+package dns
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ErrKey is returned when an RR_RRSIG is missing the fields (KeyTag,
+// SignerName, Algorithm) it needs before it can be signed or verified,
+// or when a DNSKEY does not match the signature it is checked against.
+var ErrKey = &Error{err: "bad key"}
+
+// ErrRRset is returned when an RRset handed to Sign or Verify is empty
+// or contains records of more than one type, and by Sign when its
+// records do not all share the same owner name and class.
+var ErrRRset = &Error{err: "bad rrset"}
+
+// ErrSig is returned by Verify when the cryptographic signature check
+// fails.
+var ErrSig = &Error{err: "bad signature"}
+
+// Sign signs rrset, a set of records that must all share the same
+// owner name, class and type, with the private key k and stores the
+// result in rr.Signature. The caller must set Algorithm, SignerName
+// and KeyTag on rr beforehand; TypeCovered, Labels and OrigTtl are
+// derived from rrset.
+func (rr *RR_RRSIG) Sign(k PrivateKey, rrset []RR) error {
+	if k == nil {
+		return ErrPrivKey
+	}
+	if rr.KeyTag == 0 || rr.SignerName == "" || rr.Algorithm == 0 {
+		return ErrKey
+	}
+	if len(rrset) == 0 {
+		return ErrRRset
+	}
+
+	h0 := rrset[0].Header()
+	for _, r := range rrset[1:] {
+		h := r.Header()
+		if h.Name != h0.Name || h.Class != h0.Class {
+			return ErrRRset
+		}
+	}
+
+	rr.Hdr.Name = h0.Name
+	rr.Hdr.Class = h0.Class
+	rr.Hdr.Rrtype = TypeRRSIG
+	rr.Hdr.Ttl = h0.Ttl
+	rr.OrigTtl = h0.Ttl
+	rr.TypeCovered = h0.Rrtype
+	labels := len(splitCanonicalLabels(h0.Name))
+	if strings.HasPrefix(h0.Name, "*.") {
+		labels--
+	}
+	rr.Labels = uint8(labels)
+
+	buf, err := rawSignatureData(rrset, rr)
+	if err != nil {
+		return err
+	}
+
+	switch p := k.(type) {
+	case *rsa.PrivateKey:
+		h, ok := hashForAlgorithm(rr.Algorithm)
+		if !ok {
+			return ErrAlg
+		}
+		sig, err := rsa.SignPKCS1v15(rand.Reader, p, h, hashSum(h, buf))
+		if err != nil {
+			return err
+		}
+		rr.Signature = unpackBase64(sig)
+	case *ecdsa.PrivateKey:
+		h, ok := hashForAlgorithm(rr.Algorithm)
+		if !ok {
+			return ErrAlg
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, p, hashSum(h, buf))
+		if err != nil {
+			return err
+		}
+		size := curveSize(p.Curve)
+		sig := make([]byte, 2*size)
+		copy(sig[size-len(r.Bytes()):size], r.Bytes())
+		copy(sig[2*size-len(s.Bytes()):], s.Bytes())
+		rr.Signature = unpackBase64(sig)
+	case ed25519.PrivateKey:
+		// RFC 8080: Ed25519 signs the canonical wire form directly,
+		// with no prehash.
+		rr.Signature = unpackBase64(ed25519.Sign(p, buf))
+	default:
+		return ErrAlg
+	}
+	return nil
+}
+
+// Verify checks that rr is a valid signature, made by k, over rrset.
+func (rr *RR_RRSIG) Verify(k *RR_DNSKEY, rrset []RR) error {
+	if k == nil {
+		return ErrKey
+	}
+	if rr.KeyTag != k.KeyTag() {
+		return ErrKey
+	}
+	if rr.SignerName != k.Hdr.Name {
+		return ErrKey
+	}
+	if rr.Algorithm != k.Algorithm {
+		return ErrKey
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(rr.Signature)
+	if err != nil {
+		return err
+	}
+
+	buf, err := rawSignatureData(rrset, rr)
+	if err != nil {
+		return err
+	}
+
+	if k.Algorithm == ED25519 {
+		pub, err := k.publicKeyEd25519()
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(pub, buf, sig) {
+			return ErrSig
+		}
+		return nil
+	}
+
+	h, ok := hashForAlgorithm(k.Algorithm)
+	if !ok {
+		return ErrAlg
+	}
+	hashed := hashSum(h, buf)
+
+	switch k.Algorithm {
+	case RSAMD5, RSASHA1, RSASHA256, RSASHA512, RSASHA1NSEC3SHA1:
+		pub, err := k.publicKeyRSA()
+		if err != nil {
+			return err
+		}
+		if err := rsa.VerifyPKCS1v15(pub, h, hashed, sig); err != nil {
+			return ErrSig
+		}
+		return nil
+	case ECDSAP256SHA256Y, ECDSAP384SHA384Y:
+		pub, size, err := k.publicKeyECDSA()
+		if err != nil {
+			return err
+		}
+		if len(sig) != 2*size {
+			return ErrSig
+		}
+		r := big.NewInt(0).SetBytes(sig[:size])
+		s := big.NewInt(0).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, hashed, r, s) {
+			return ErrSig
+		}
+		return nil
+	}
+	return ErrAlg
+}
+
+// KeyTag returns the RFC 4034 Appendix B key tag for k, used to match
+// an RRSIG's KeyTag field to the DNSKEY that created it.
+func (k *RR_DNSKEY) KeyTag() uint16 {
+	if k == nil {
+		return 0
+	}
+	rdata, err := k.rdataToWire()
+	if err != nil {
+		return 0
+	}
+
+	if k.Algorithm == RSAMD5 {
+		// RFC 4034 Appendix B.1: algorithm 1 uses the most significant
+		// 16 of the least significant 24 bits of the modulus as its tag,
+		// i.e. the third- and second-to-last bytes of the RDATA.
+		if len(rdata) < 3 {
+			return 0
+		}
+		return uint16(rdata[len(rdata)-3])<<8 | uint16(rdata[len(rdata)-2])
+	}
+
+	var tag uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			tag += uint32(b) << 8
+		} else {
+			tag += uint32(b)
+		}
+	}
+	tag += (tag >> 16) & 0xFFFF
+	return uint16(tag & 0xFFFF)
+}
+
+// rdataToWire returns the wire form of k's RDATA: Flags, Protocol,
+// Algorithm and the raw public key, as used by both KeyTag and ToDS.
+func (k *RR_DNSKEY) rdataToWire() ([]byte, error) {
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4+len(pub))
+	binary.BigEndian.PutUint16(buf[0:], k.Flags)
+	buf[2] = k.Protocol
+	buf[3] = k.Algorithm
+	copy(buf[4:], pub)
+	return buf, nil
+}
+
+// publicKeyRSA reconstructs an *rsa.PublicKey from k's RFC 3110
+// wire-format public key.
+func (k *RR_DNSKEY) publicKeyRSA() (*rsa.PublicKey, error) {
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) == 0 {
+		return nil, ErrKey
+	}
+
+	explen := int(pub[0])
+	keyoff := 1
+	if explen == 0 {
+		if len(pub) < 3 {
+			return nil, ErrKey
+		}
+		explen = int(pub[1])<<8 | int(pub[2])
+		keyoff = 3
+	}
+	if len(pub) < keyoff+explen {
+		return nil, ErrKey
+	}
+
+	e := big.NewInt(0).SetBytes(pub[keyoff : keyoff+explen])
+	n := big.NewInt(0).SetBytes(pub[keyoff+explen:])
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// publicKeyECDSA reconstructs an *ecdsa.PublicKey from k's RFC 6605
+// wire-format public key (the concatenation of X and Y) along with
+// the curve's field element size in bytes.
+func (k *RR_DNSKEY) publicKeyECDSA() (*ecdsa.PublicKey, int, error) {
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var c elliptic.Curve
+	switch k.Algorithm {
+	case ECDSAP256SHA256Y:
+		c = elliptic.P256()
+	case ECDSAP384SHA384Y:
+		c = elliptic.P384()
+	default:
+		return nil, 0, ErrAlg
+	}
+
+	size := curveSize(c)
+	if len(pub) != 2*size {
+		return nil, 0, ErrKey
+	}
+
+	x := big.NewInt(0).SetBytes(pub[:size])
+	y := big.NewInt(0).SetBytes(pub[size:])
+
+	return &ecdsa.PublicKey{Curve: c, X: x, Y: y}, size, nil
+}
+
+// publicKeyEd25519 returns k's raw Ed25519 public key.
+func (k *RR_DNSKEY) publicKeyEd25519() (ed25519.PublicKey, error) {
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, ErrKey
+	}
+	return ed25519.PublicKey(pub), nil
+}
+
+// ToDS builds a DS record for k using the requested digest algorithm:
+// 1 (SHA-1), 2 (SHA-256) or 4 (SHA-384, RFC 6605). It returns nil if
+// digestType is not one of these or k cannot be read.
+func (k *RR_DNSKEY) ToDS(digestType uint8) *RR_DS {
+	rdata, err := k.rdataToWire()
+	if err != nil {
+		return nil
+	}
+	owner, err := canonicalOwnerWire(k.Hdr.Name)
+	if err != nil {
+		return nil
+	}
+
+	wire := append(owner, rdata...)
+
+	var digest []byte
+	switch digestType {
+	case 1:
+		sum := sha1.Sum(wire)
+		digest = sum[:]
+	case 2:
+		sum := sha256.Sum256(wire)
+		digest = sum[:]
+	case 4:
+		sum := sha512.Sum384(wire)
+		digest = sum[:]
+	default:
+		return nil
+	}
+
+	ds := new(RR_DS)
+	ds.Hdr.Name = k.Hdr.Name
+	ds.Hdr.Rrtype = TypeDS
+	ds.Hdr.Class = k.Hdr.Class
+	ds.Hdr.Ttl = k.Hdr.Ttl
+	ds.KeyTag = k.KeyTag()
+	ds.Algorithm = k.Algorithm
+	ds.DigestType = digestType
+	ds.Digest = hex.EncodeToString(digest)
+	return ds
+}
+
+// hashForAlgorithm returns the hash construction used to sign and
+// verify RRSIGs for the given DNSKEY algorithm.
+func hashForAlgorithm(alg uint8) (crypto.Hash, bool) {
+	switch alg {
+	case RSAMD5:
+		return 0, false // RFC 6725 deprecates algorithm 1; neither signing nor verification is supported.
+	case RSASHA1, RSASHA1NSEC3SHA1:
+		return crypto.SHA1, true
+	case RSASHA256, ECDSAP256SHA256Y:
+		return crypto.SHA256, true
+	case RSASHA512:
+		return crypto.SHA512, true
+	case ECDSAP384SHA384Y:
+		return crypto.SHA384, true
+	}
+	return 0, false
+}
+
+func hashSum(h crypto.Hash, buf []byte) []byte {
+	hasher := h.New()
+	hasher.Write(buf)
+	return hasher.Sum(nil)
+}
+
+// packRRSIGData returns the wire form of rr's RDATA fields that
+// precede the signature itself (RFC 4034 Section 3.1.8.1), the
+// portion of the signed data that is not repeated per-record.
+func packRRSIGData(rr *RR_RRSIG) ([]byte, error) {
+	name, err := canonicalOwnerWire(rr.SignerName)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 18+len(name))
+	binary.BigEndian.PutUint16(buf[0:], rr.TypeCovered)
+	buf[2] = rr.Algorithm
+	buf[3] = rr.Labels
+	binary.BigEndian.PutUint32(buf[4:], rr.OrigTtl)
+	binary.BigEndian.PutUint32(buf[8:], rr.Expiration)
+	binary.BigEndian.PutUint32(buf[12:], rr.Inception)
+	binary.BigEndian.PutUint16(buf[16:], rr.KeyTag)
+	copy(buf[18:], name)
+	return buf, nil
+}
+
+// rawSignatureData returns rrset in the canonical wire form described
+// by RFC 4034 Section 6.2: the RRSIG fields that precede the
+// signature, followed by every record with its owner name lower-cased
+// and its TTL replaced by OrigTtl, sorted into canonical order with
+// duplicates removed.
+func rawSignatureData(rrset []RR, s *RR_RRSIG) ([]byte, error) {
+	if len(rrset) == 0 {
+		return nil, ErrRRset
+	}
+
+	type canonicalRR struct {
+		wire, rdata string
+	}
+	wires := make([]canonicalRR, 0, len(rrset))
+	for _, r := range rrset {
+		h := r.Header()
+		if h.Rrtype != s.TypeCovered {
+			return nil, ErrRRset
+		}
+
+		name, ttl := h.Name, h.Ttl
+		h.Name = strings.ToLower(name)
+		h.Ttl = s.OrigTtl
+		wire := make([]byte, r.len()+1)
+		off, err := packRR(r, wire, 0, nil, false)
+		h.Name, h.Ttl = name, ttl
+		if err != nil {
+			return nil, err
+		}
+
+		owner, err := canonicalOwnerWire(h.Name)
+		if err != nil {
+			return nil, err
+		}
+		// The RDATA follows the owner name and the 10 bytes of type,
+		// class, TTL and RDLENGTH.
+		w := string(wire[:off])
+		wires = append(wires, canonicalRR{wire: w, rdata: w[len(owner)+10:]})
+	}
+	// RFC 4034 Section 6.3: records are ordered by their RDATA alone.
+	sort.Slice(wires, func(i, j int) bool { return wires[i].rdata < wires[j].rdata })
+
+	sigwire, err := packRRSIGData(s)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(sigwire)
+	prev := ""
+	for _, w := range wires {
+		if w.wire == prev {
+			continue
+		}
+		buf.WriteString(w.wire)
+		prev = w.wire
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalOwnerWire encodes name as a lower-cased, uncompressed wire
+// format domain name, as required for DNSSEC canonical ordering.
+func canonicalOwnerWire(name string) ([]byte, error) {
+	labels := splitCanonicalLabels(strings.ToLower(name))
+	buf := new(bytes.Buffer)
+	for _, l := range labels {
+		if len(l) > 63 {
+			return nil, ErrRRset
+		}
+		buf.WriteByte(byte(len(l)))
+		buf.WriteString(l)
+	}
+	buf.WriteByte(0)
+	return buf.Bytes(), nil
+}
+
+// splitCanonicalLabels splits a domain name into its labels, honouring
+// backslash-escaped dots.
+func splitCanonicalLabels(s string) []string {
+	if s == "." || s == "" {
+		return nil
+	}
+	var labels []string
+	var cur []byte
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			cur = append(cur, c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '.':
+			labels = append(labels, string(cur))
+			cur = nil
+		default:
+			cur = append(cur, c)
+		}
+	}
+	if len(cur) > 0 {
+		labels = append(labels, string(cur))
+	}
+	return labels
+}
@@ -1,18 +1,38 @@
 package dns
 
 import (
+	"bufio"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
+	"io"
 	"math/big"
 	"strconv"
+	"strings"
+)
+
+// EdDSA algorithms, RFC 8080. ED448 has no case in Generate below: the
+// standard library has no crypto/ed448, so it is not wired up here.
+// Support for it belongs in a file gated with its own build tag once
+// an ed448 implementation is available to build against.
+const (
+	ED25519 uint8 = 15
+	ED448   uint8 = 16
 )
 
 // Empty interface that is used as a wrapper around all possible
 // private key implementations from the crypto package.
 type PrivateKey interface{}
 
+// ErrPrivKey is returned by ReadPrivateKey when the key file lacks a
+// usable Algorithm line or a required field, and by Sign when it is
+// given a nil key. A field that is not valid base64 yields the base64
+// decoding error instead.
+var ErrPrivKey = &Error{err: "malformed private key"}
+
 // Generate generates a DNSKEY of the given bit size.
 // The public part is put inside the DNSKEY record. 
 // The Algorithm in the key must be set as this will define
@@ -37,6 +57,10 @@ func (r *RR_DNSKEY) Generate(bits int) (PrivateKey, error) {
 		if bits != 384 {
 			return nil, ErrKeySize
 		}
+	case ED25519:
+		if bits != 256 {
+			return nil, ErrKeySize
+		}
 	}
 
 	switch r.Algorithm {
@@ -61,6 +85,13 @@ func (r *RR_DNSKEY) Generate(bits int) (PrivateKey, error) {
 		}
 		r.setPublicKeyCurve(priv.PublicKey.X, priv.PublicKey.Y)
 		return priv, nil
+	case ED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		r.setPublicKeyEd25519(pub)
+		return priv, nil
 	default:
 		return nil, ErrAlg
 	}
@@ -105,7 +136,222 @@ func (r *RR_DNSKEY) PrivateKeyString(p PrivateKey) (s string) {
 			"Exponent2: " + exponent2 + "\n" +
 			"Coefficient: " + coefficient + "\n"
 	case *ecdsa.PrivateKey:
-		s = "TODO"
+		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + Alg_str[r.Algorithm] + ")"
+		size := curveSize(t.Curve)
+		privateKey := unpackBase64(intToBytes(t.D, size))
+
+		s = "Private-key-format: v1.3\n" +
+			"Algorithm: " + algorithm + "\n" +
+			"PrivateKey: " + privateKey + "\n"
+	case ed25519.PrivateKey:
+		algorithm := strconv.Itoa(int(r.Algorithm)) + " (" + Alg_str[r.Algorithm] + ")"
+		privateKey := unpackBase64(t.Seed())
+
+		s = "Private-key-format: v1.3\n" +
+			"Algorithm: " + algorithm + "\n" +
+			"PrivateKey: " + privateKey + "\n"
 	}
 	return
 }
+
+// setPublicKeyEd25519 sets the public key in the DNSKEY to the
+// 32-byte raw Ed25519 public key pub.
+func (r *RR_DNSKEY) setPublicKeyEd25519(pub ed25519.PublicKey) {
+	r.PublicKey = unpackBase64(pub)
+}
+
+// curveSize returns the size, in bytes, of a big.Int field element
+// for the given elliptic curve, 32 for P-256 and 48 for P-384.
+func curveSize(c elliptic.Curve) int {
+	return (c.Params().BitSize + 7) / 8
+}
+
+// intToBytes converts i to a big-endian byte slice of exactly size
+// bytes, left-padding with zeroes as needed.
+func intToBytes(i *big.Int, size int) []byte {
+	buf := make([]byte, size)
+	b := i.Bytes()
+	copy(buf[size-len(b):], b)
+	return buf
+}
+
+// ReadPrivateKey reads a private key from q in BIND's Private-key-format
+// (v1.3), as written by PrivateKeyString and by BIND9's dnssec-keygen.
+// It supports RSA, ECDSA and Ed25519 keys.
+func ReadPrivateKey(q io.Reader) (PrivateKey, error) {
+	m, err := parsePrivateKeyFile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	algstr := m["algorithm"]
+	if algstr == "" {
+		return nil, ErrPrivKey
+	}
+	// "Algorithm: 5 (RSASHA1)" -- only the leading number matters.
+	fields := strings.Fields(algstr)
+	if len(fields) == 0 {
+		return nil, ErrPrivKey
+	}
+	alg, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, ErrPrivKey
+	}
+
+	switch uint8(alg) {
+	case RSAMD5, RSASHA1, RSASHA256, RSASHA512, RSASHA1NSEC3SHA1:
+		return readPrivateKeyRSA(m)
+	case ECDSAP256SHA256Y, ECDSAP384SHA384Y:
+		return readPrivateKeyECDSA(uint8(alg), m)
+	case ED25519:
+		return readPrivateKeyEd25519(m)
+	}
+	return nil, ErrAlg
+}
+
+// ReadPrivateKey reads a private key from q, see ReadPrivateKey.
+func (r *RR_DNSKEY) ReadPrivateKey(q io.Reader) (PrivateKey, error) {
+	return ReadPrivateKey(q)
+}
+
+// parsePrivateKeyFile reads "Key: Value" lines from q and returns the
+// trimmed values keyed by the trimmed, lower-cased key.
+func parsePrivateKeyFile(q io.Reader) (map[string]string, error) {
+	m := make(map[string]string)
+	scanner := bufio.NewScanner(q)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		m[key] = val
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// bigFromBase64 decodes a base64 field from a BIND private key file
+// into a big.Int, as used by the RSA and ECDSA integer fields.
+func bigFromBase64(s string) (*big.Int, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(0).SetBytes(b), nil
+}
+
+func readPrivateKeyRSA(m map[string]string) (PrivateKey, error) {
+	// BIND9's dnssec-keygen spells this field "Modulus", but the typo
+	// in PrivateKeyString above ("Modules") must still round-trip.
+	modulus, ok := m["modulus"]
+	if !ok {
+		modulus, ok = m["modules"]
+	}
+	if !ok {
+		return nil, ErrPrivKey
+	}
+	publicExponent, ok := m["publicexponent"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+	privateExponent, ok := m["privateexponent"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+	prime1, ok := m["prime1"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+	prime2, ok := m["prime2"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+
+	p := new(rsa.PrivateKey)
+	p.Primes = make([]*big.Int, 2)
+
+	n, err := bigFromBase64(modulus)
+	if err != nil {
+		return nil, err
+	}
+	e, err := bigFromBase64(publicExponent)
+	if err != nil {
+		return nil, err
+	}
+	d, err := bigFromBase64(privateExponent)
+	if err != nil {
+		return nil, err
+	}
+	p1, err := bigFromBase64(prime1)
+	if err != nil {
+		return nil, err
+	}
+	p2, err := bigFromBase64(prime2)
+	if err != nil {
+		return nil, err
+	}
+
+	p.PublicKey.N = n
+	p.PublicKey.E = int(e.Int64())
+	p.D = d
+	p.Primes[0] = p1
+	p.Primes[1] = p2
+	p.Precompute()
+
+	return p, nil
+}
+
+func readPrivateKeyECDSA(alg uint8, m map[string]string) (PrivateKey, error) {
+	var c elliptic.Curve
+	switch alg {
+	case ECDSAP256SHA256Y:
+		c = elliptic.P256()
+	case ECDSAP384SHA384Y:
+		c = elliptic.P384()
+	}
+
+	privateKey, ok := m["privatekey"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+
+	d, err := bigFromBase64(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	x, y := c.ScalarBaseMult(d.Bytes())
+
+	p := new(ecdsa.PrivateKey)
+	p.D = d
+	p.PublicKey.Curve = c
+	p.PublicKey.X = x
+	p.PublicKey.Y = y
+
+	return p, nil
+}
+
+func readPrivateKeyEd25519(m map[string]string) (PrivateKey, error) {
+	privateKey, ok := m["privatekey"]
+	if !ok {
+		return nil, ErrPrivKey
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, ErrPrivKey
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,90 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"strings"
+	"testing"
+)
+
+func TestReadPrivateKeyRoundTripRSA(t *testing.T) {
+	key := &RR_DNSKEY{Algorithm: RSASHA256}
+	priv, err := key.Generate(1024)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want := priv.(*rsa.PrivateKey)
+
+	got, err := key.ReadPrivateKey(strings.NewReader(key.PrivateKeyString(priv)))
+	if err != nil {
+		t.Fatalf("ReadPrivateKey: %v", err)
+	}
+	p, ok := got.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatalf("ReadPrivateKey returned %T, want *rsa.PrivateKey", got)
+	}
+	if p.N.Cmp(want.N) != 0 || p.E != want.E || p.D.Cmp(want.D) != 0 {
+		t.Fatal("round-tripped RSA key does not match the generated key")
+	}
+}
+
+func TestReadPrivateKeyRoundTripECDSA(t *testing.T) {
+	key := &RR_DNSKEY{Algorithm: ECDSAP256SHA256Y}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want := priv.(*ecdsa.PrivateKey)
+
+	got, err := key.ReadPrivateKey(strings.NewReader(key.PrivateKeyString(priv)))
+	if err != nil {
+		t.Fatalf("ReadPrivateKey: %v", err)
+	}
+	p, ok := got.(*ecdsa.PrivateKey)
+	if !ok {
+		t.Fatalf("ReadPrivateKey returned %T, want *ecdsa.PrivateKey", got)
+	}
+	if p.D.Cmp(want.D) != 0 || p.X.Cmp(want.X) != 0 || p.Y.Cmp(want.Y) != 0 {
+		t.Fatal("round-tripped ECDSA key does not match the generated key")
+	}
+}
+
+func TestReadPrivateKeyRoundTripEd25519(t *testing.T) {
+	key := &RR_DNSKEY{Algorithm: ED25519}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want := priv.(ed25519.PrivateKey)
+
+	got, err := key.ReadPrivateKey(strings.NewReader(key.PrivateKeyString(priv)))
+	if err != nil {
+		t.Fatalf("ReadPrivateKey: %v", err)
+	}
+	p, ok := got.(ed25519.PrivateKey)
+	if !ok {
+		t.Fatalf("ReadPrivateKey returned %T, want ed25519.PrivateKey", got)
+	}
+	if !bytes.Equal(p, want) {
+		t.Fatal("round-tripped Ed25519 key does not match the generated key")
+	}
+}
+
+func TestReadPrivateKeyMissingField(t *testing.T) {
+	// A truncated RSA key file missing everything past the modulus.
+	const truncated = "Private-key-format: v1.3\n" +
+		"Algorithm: 8 (RSASHA256)\n" +
+		"Modulus: AQAB\n"
+	if _, err := ReadPrivateKey(strings.NewReader(truncated)); err != ErrPrivKey {
+		t.Fatalf("ReadPrivateKey on a truncated RSA key = %v, want ErrPrivKey", err)
+	}
+
+	// A truncated ECDSA key file missing the PrivateKey field.
+	const truncatedECDSA = "Private-key-format: v1.3\n" +
+		"Algorithm: 13 (ECDSAP256SHA256)\n"
+	if _, err := ReadPrivateKey(strings.NewReader(truncatedECDSA)); err != ErrPrivKey {
+		t.Fatalf("ReadPrivateKey on a truncated ECDSA key = %v, want ErrPrivKey", err)
+	}
+}